@@ -0,0 +1,39 @@
+package keymaster
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClusterAuthMount(t *testing.T) {
+	cluster := Cluster{
+		Name: "bravo",
+		AuthMounts: map[Environment]string{
+			Dev:  "auth/k8s-bravo-dev",
+			Prod: "auth/k8s-bravo-prod",
+		},
+	}
+
+	mount, err := cluster.AuthMount(Dev)
+	assert.NoError(t, err)
+	assert.Equal(t, "auth/k8s-bravo-dev", mount)
+
+	_, err = cluster.AuthMount(Stage)
+	assert.Error(t, err)
+}
+
+func TestNamespacedAuthMounts(t *testing.T) {
+	km := NewKeyMaster(kmClient)
+
+	enclave, err := km.NewEnclave("acme")
+	assert.NoError(t, err)
+
+	mounts := namespacedAuthMounts(map[Environment]string{
+		Dev:  "auth/k8s-bravo-dev",
+		Prod: "auth/k8s-bravo-prod",
+	}, enclave.authMount)
+
+	assert.Equal(t, "auth/acme/k8s-bravo-dev", mounts[Dev])
+	assert.Equal(t, "auth/acme/k8s-bravo-prod", mounts[Prod])
+}
@@ -0,0 +1,261 @@
+package keymaster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// RealmAws identifies a Realm that maps a Role to a Vault AWS
+// secrets-engine role.
+const RealmAws = "aws"
+
+// AwsCredentialType selects how Vault's AWS secrets engine mints
+// credentials for a role.
+type AwsCredentialType string
+
+const (
+	AwsIamUser         AwsCredentialType = "iam_user"
+	AwsAssumedRole     AwsCredentialType = "assumed_role"
+	AwsFederationToken AwsCredentialType = "federation_token"
+	AwsSessionToken    AwsCredentialType = "session_token"
+)
+
+// AwsRole is the strongly-typed form of a Vault AWS secrets-engine role.
+type AwsRole struct {
+	CredentialType         AwsCredentialType
+	RoleArns               []string
+	PolicyArns             []string
+	PolicyDocument         map[string]interface{}
+	PermissionsBoundaryArn string
+	DefaultStsTTL          string
+	MaxStsTTL              string
+	UserPath               string
+}
+
+// Validate checks the field combinations Vault itself enforces for each
+// AwsCredentialType: iam_user forbids RoleArns, assumed_role requires them.
+func (r AwsRole) Validate() (err error) {
+	switch r.CredentialType {
+	case AwsIamUser:
+		if len(r.RoleArns) > 0 {
+			err = errors.New("iam_user credential type does not accept role_arns")
+			return err
+		}
+	case AwsAssumedRole:
+		if len(r.RoleArns) == 0 {
+			err = errors.New("assumed_role credential type requires at least one role_arn")
+			return err
+		}
+	case AwsFederationToken, AwsSessionToken:
+		if len(r.RoleArns) > 0 {
+			err = errors.Errorf("%s credential type does not accept role_arns", r.CredentialType)
+			return err
+		}
+	default:
+		err = errors.Errorf("unsupported aws credential_type %q", r.CredentialType)
+		return err
+	}
+
+	return err
+}
+
+func awsRolePath(name string) string {
+	return fmt.Sprintf("aws/roles/%s", name)
+}
+
+// WriteAwsRole provisions a Vault AWS secrets-engine role for role so it can
+// mint AWS credentials of the configured CredentialType.
+func (km *KeyMaster) WriteAwsRole(role *Role, env Environment, awsRole AwsRole) (err error) {
+	name, err := km.PolicyName(role.Name, role.Namespace, env)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to create policy name")
+		return err
+	}
+
+	if err = km.writeAwsRole(name, awsRole); err != nil {
+		err = errors.Wrapf(err, "failed to write aws role for %s", role.Name)
+		return err
+	}
+
+	return err
+}
+
+func (km *KeyMaster) writeAwsRole(name string, awsRole AwsRole) (err error) {
+	if err = awsRole.Validate(); err != nil {
+		err = errors.Wrapf(err, "invalid aws role for %s", name)
+		return err
+	}
+
+	policyDocument := ""
+	if awsRole.PolicyDocument != nil {
+		raw, err := json.Marshal(awsRole.PolicyDocument)
+		if err != nil {
+			err = errors.Wrapf(err, "failed to marshal policy_document for %s", name)
+			return err
+		}
+
+		policyDocument = string(raw)
+	}
+
+	body := map[string]interface{}{
+		"credential_type":          string(awsRole.CredentialType),
+		"role_arns":                awsRole.RoleArns,
+		"policy_arns":              awsRole.PolicyArns,
+		"policy_document":          policyDocument,
+		"permissions_boundary_arn": awsRole.PermissionsBoundaryArn,
+		"default_sts_ttl":          awsRole.DefaultStsTTL,
+		"max_sts_ttl":              awsRole.MaxStsTTL,
+		"user_path":                awsRole.UserPath,
+	}
+
+	r := km.VaultClient.NewRequest("PUT", fmt.Sprintf("/v1/%s", awsRolePath(name)))
+	if err := r.SetJSONBody(body); err != nil {
+		err = errors.Wrapf(err, "failed to set json body on request")
+		return err
+	}
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+
+	resp, err := km.VaultClient.RawRequestWithContext(ctx, r)
+	if err != nil {
+		err = errors.Wrapf(err, "aws role write failed for %s", name)
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	return err
+}
+
+// ReadAwsRole fetches role's AWS secrets-engine role from Vault and decodes
+// it back into an AwsRole so callers don't have to pick apart the raw map
+// the way ReadPolicyFromVault's callers do.
+func (km *KeyMaster) ReadAwsRole(role *Role, env Environment) (awsRole AwsRole, err error) {
+	name, err := km.PolicyName(role.Name, role.Namespace, env)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to create policy name")
+		return awsRole, err
+	}
+
+	return km.readAwsRole(name)
+}
+
+func (km *KeyMaster) readAwsRole(name string) (awsRole AwsRole, err error) {
+	s, err := km.VaultClient.Logical().Read(awsRolePath(name))
+	if err != nil {
+		err = errors.Wrapf(err, "failed to read aws role %s", name)
+		return awsRole, err
+	}
+
+	if s == nil {
+		return awsRole, err
+	}
+
+	awsRole = AwsRole{
+		CredentialType:         AwsCredentialType(stringFromData(s.Data, "credential_type")),
+		RoleArns:               stringsFromInterfaceSlice(s.Data["role_arns"]),
+		PolicyArns:             stringsFromInterfaceSlice(s.Data["policy_arns"]),
+		PermissionsBoundaryArn: stringFromData(s.Data, "permissions_boundary_arn"),
+		DefaultStsTTL:          durationStringFromData(s.Data, "default_sts_ttl"),
+		MaxStsTTL:              durationStringFromData(s.Data, "max_sts_ttl"),
+		UserPath:               stringFromData(s.Data, "user_path"),
+	}
+
+	if doc := stringFromData(s.Data, "policy_document"); doc != "" {
+		if err = json.Unmarshal([]byte(doc), &awsRole.PolicyDocument); err != nil {
+			err = errors.Wrapf(err, "failed to unmarshal policy_document for %s", name)
+			return awsRole, err
+		}
+	}
+
+	return awsRole, err
+}
+
+// AddPolicyToAwsRole appends policyArn to role's AWS secrets-engine role
+// policy_arns, leaving every other field untouched.
+func (km *KeyMaster) AddPolicyToAwsRole(role *Role, env Environment, policyArn string) (err error) {
+	name, err := km.PolicyName(role.Name, role.Namespace, env)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to create policy name")
+		return err
+	}
+
+	awsRole, err := km.readAwsRole(name)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to read aws role")
+		return err
+	}
+
+	if !containsString(awsRole.PolicyArns, policyArn) {
+		awsRole.PolicyArns = append(awsRole.PolicyArns, policyArn)
+	}
+
+	if err = km.writeAwsRole(name, awsRole); err != nil {
+		err = errors.Wrapf(err, "failed to write aws role for %s", role.Name)
+		return err
+	}
+
+	return err
+}
+
+// WriteAwsRole writes role's AWS secrets-engine role under this enclave's
+// policy namespace, so AWS role names stay unique per tenant the same way
+// Enclave.PolicyName already scopes Vault policies.
+func (e *Enclave) WriteAwsRole(role *Role, env Environment, awsRole AwsRole) (err error) {
+	name, err := e.PolicyName(role.Name, role.Namespace, env)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to create policy name")
+		return err
+	}
+
+	if err = e.writeAwsRole(name, awsRole); err != nil {
+		err = errors.Wrapf(err, "failed to write aws role for %s", role.Name)
+		return err
+	}
+
+	return err
+}
+
+// ReadAwsRole fetches role's AWS secrets-engine role from this enclave's
+// policy namespace.
+func (e *Enclave) ReadAwsRole(role *Role, env Environment) (awsRole AwsRole, err error) {
+	name, err := e.PolicyName(role.Name, role.Namespace, env)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to create policy name")
+		return awsRole, err
+	}
+
+	return e.KeyMaster.readAwsRole(name)
+}
+
+// AddPolicyToAwsRole appends policyArn to role's AWS secrets-engine role
+// policy_arns under this enclave's policy namespace, leaving every other
+// field untouched.
+func (e *Enclave) AddPolicyToAwsRole(role *Role, env Environment, policyArn string) (err error) {
+	name, err := e.PolicyName(role.Name, role.Namespace, env)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to create policy name")
+		return err
+	}
+
+	awsRole, err := e.KeyMaster.readAwsRole(name)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to read aws role")
+		return err
+	}
+
+	if !containsString(awsRole.PolicyArns, policyArn) {
+		awsRole.PolicyArns = append(awsRole.PolicyArns, policyArn)
+	}
+
+	if err = e.writeAwsRole(name, awsRole); err != nil {
+		err = errors.Wrapf(err, "failed to write aws role for %s", role.Name)
+		return err
+	}
+
+	return err
+}
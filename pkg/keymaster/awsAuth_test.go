@@ -0,0 +1,180 @@
+package keymaster
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAwsAuthCrud(t *testing.T) {
+	km := NewKeyMaster(kmClient)
+
+	role := &Role{
+		Name: "app1",
+		Team: "core-services",
+		Realms: []*Realm{
+			&Realm{
+				Type:        RealmAws,
+				Identifiers: []string{"aws-bravo"},
+			},
+		},
+	}
+
+	awsRole := AwsRole{
+		CredentialType: AwsAssumedRole,
+		RoleArns:       []string{"arn:aws:iam::123456789012:role/app1"},
+		PolicyDocument: map[string]interface{}{
+			"Version": "2012-10-17",
+			"Statement": []interface{}{
+				map[string]interface{}{
+					"Effect":   "Allow",
+					"Action":   "s3:GetObject",
+					"Resource": "*",
+				},
+			},
+		},
+		DefaultStsTTL: "1h",
+		MaxStsTTL:     "4h",
+	}
+
+	err := km.WriteAwsRole(role, "development", awsRole)
+	if err != nil {
+		log.Printf("Error writing aws role: %s", err)
+		t.Fail()
+	}
+
+	readBack, err := km.ReadAwsRole(role, "development")
+	if err != nil {
+		fmt.Printf("Failed reading aws role: %s", err)
+		t.Fail()
+	}
+
+	// Vault normalizes the ttl fields to a json.Number of seconds on read,
+	// so the strings that survive the round trip are time.Duration's
+	// canonical form rather than the shorthand that was written.
+	expected := AwsRole{
+		CredentialType: AwsAssumedRole,
+		RoleArns:       []string{"arn:aws:iam::123456789012:role/app1"},
+		PolicyDocument: awsRole.PolicyDocument,
+		DefaultStsTTL:  "1h0m0s",
+		MaxStsTTL:      "4h0m0s",
+	}
+
+	assert.True(t, reflect.DeepEqual(readBack, expected))
+
+	err = km.AddPolicyToAwsRole(role, "development", "arn:aws:iam::aws:policy/ReadOnlyAccess")
+	if err != nil {
+		fmt.Printf("Failed adding policy arn: %s", err)
+		t.Fail()
+	}
+
+	readBack, err = km.ReadAwsRole(role, "development")
+	if err != nil {
+		fmt.Printf("Failed reading aws role: %s", err)
+		t.Fail()
+	}
+
+	assert.True(t, reflect.DeepEqual(readBack.PolicyArns, []string{"arn:aws:iam::aws:policy/ReadOnlyAccess"}))
+}
+
+func TestEnclaveAwsAuthCrud(t *testing.T) {
+	km := NewKeyMaster(kmClient)
+
+	enclave, err := km.NewEnclave("acme")
+	assert.NoError(t, err)
+
+	role := &Role{
+		Name: "app1",
+		Team: "core-services",
+		Realms: []*Realm{
+			&Realm{
+				Type:        RealmAws,
+				Identifiers: []string{"aws-bravo"},
+			},
+		},
+	}
+
+	awsRole := AwsRole{
+		CredentialType: AwsAssumedRole,
+		RoleArns:       []string{"arn:aws:iam::123456789012:role/app1"},
+		DefaultStsTTL:  "1h",
+		MaxStsTTL:      "4h",
+	}
+
+	// TestAwsAuthCrud writes this same role under the shared name, so the
+	// leak check below compares against what is there before the enclave
+	// writes rather than against an empty role.
+	sharedBefore, err := km.ReadAwsRole(role, "development")
+	if err != nil {
+		fmt.Printf("Failed reading shared aws role: %s", err)
+		t.Fail()
+	}
+
+	err = enclave.WriteAwsRole(role, "development", awsRole)
+	if err != nil {
+		log.Printf("Error writing enclave aws role: %s", err)
+		t.Fail()
+	}
+
+	readBack, err := enclave.ReadAwsRole(role, "development")
+	if err != nil {
+		fmt.Printf("Failed reading enclave aws role: %s", err)
+		t.Fail()
+	}
+
+	// Vault normalizes the ttl fields to a json.Number of seconds on read;
+	// see TestAwsAuthCrud for why the strings differ from what was written.
+	expected := awsRole
+	expected.DefaultStsTTL = "1h0m0s"
+	expected.MaxStsTTL = "4h0m0s"
+
+	assert.True(t, reflect.DeepEqual(readBack, expected))
+
+	err = enclave.AddPolicyToAwsRole(role, "development", "arn:aws:iam::aws:policy/ReadOnlyAccess")
+	if err != nil {
+		fmt.Printf("Failed adding enclave policy arn: %s", err)
+		t.Fail()
+	}
+
+	readBack, err = enclave.ReadAwsRole(role, "development")
+	if err != nil {
+		fmt.Printf("Failed reading enclave aws role: %s", err)
+		t.Fail()
+	}
+
+	assert.True(t, reflect.DeepEqual(readBack.PolicyArns, []string{"arn:aws:iam::aws:policy/ReadOnlyAccess"}))
+
+	// The enclave's aws role must live under its own policy-scoped name,
+	// not the shared name WriteAwsRole uses directly.
+	sharedReadBack, err := km.ReadAwsRole(role, "development")
+	if err != nil {
+		fmt.Printf("Failed reading shared aws role: %s", err)
+		t.Fail()
+	}
+
+	assert.True(t, reflect.DeepEqual(sharedReadBack, sharedBefore), "enclave aws role leaked onto the shared name")
+}
+
+func TestAwsRoleValidate(t *testing.T) {
+	cases := []struct {
+		name    string
+		role    AwsRole
+		wantErr bool
+	}{
+		{"iam_user with role_arns", AwsRole{CredentialType: AwsIamUser, RoleArns: []string{"arn:aws:iam::123456789012:role/app1"}}, true},
+		{"iam_user without role_arns", AwsRole{CredentialType: AwsIamUser}, false},
+		{"assumed_role without role_arns", AwsRole{CredentialType: AwsAssumedRole}, true},
+		{"assumed_role with role_arns", AwsRole{CredentialType: AwsAssumedRole, RoleArns: []string{"arn:aws:iam::123456789012:role/app1"}}, false},
+		{"unsupported credential_type", AwsRole{CredentialType: "bogus"}, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.role.Validate()
+			assert.Equal(t, tc.wantErr, err != nil)
+		})
+	}
+}
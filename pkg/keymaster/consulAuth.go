@@ -0,0 +1,250 @@
+package keymaster
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// RealmConsul identifies a Realm that maps a Role to a Vault Consul
+// secrets-engine role rather than a Vault auth method.
+const RealmConsul = "consul"
+
+// ConsulTokenType is the Consul ACL token type a Vault Consul role is
+// allowed to mint.
+type ConsulTokenType string
+
+const (
+	ConsulClientToken     ConsulTokenType = "client"
+	ConsulManagementToken ConsulTokenType = "management"
+)
+
+// ConsulCluster describes the Consul datacenter a Vault Consul
+// secrets-engine role issues ACL tokens against.
+type ConsulCluster struct {
+	Address    string
+	Datacenter string
+	CABundle   string
+}
+
+func consulRolePath(name string) string {
+	return fmt.Sprintf("consul/roles/%s", name)
+}
+
+// ConfigureConsulAccess writes the Vault Consul secrets-engine's
+// consul/config/access so Vault can reach cluster's Consul datacenter
+// before any role can mint tokens against it. It is idempotent and safe to
+// call before every WriteConsulAuth.
+func (km *KeyMaster) ConfigureConsulAccess(cluster ConsulCluster) (err error) {
+	body := map[string]interface{}{
+		"address": cluster.Address,
+		"ca_cert": cluster.CABundle,
+	}
+
+	r := km.VaultClient.NewRequest("PUT", "/v1/consul/config/access")
+	if err := r.SetJSONBody(body); err != nil {
+		err = errors.Wrapf(err, "failed to set json body on request")
+		return err
+	}
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+
+	resp, err := km.VaultClient.RawRequestWithContext(ctx, r)
+	if err != nil {
+		err = errors.Wrapf(err, "consul config/access write failed")
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	return err
+}
+
+func (km *KeyMaster) writeConsulRole(name string, cluster ConsulCluster, consulPolicies []string, tokenType ConsulTokenType, ttl string, maxTTL string) (err error) {
+	if err = km.ConfigureConsulAccess(cluster); err != nil {
+		err = errors.Wrapf(err, "failed to configure consul access for %s", name)
+		return err
+	}
+
+	body := map[string]interface{}{
+		"policies":   consulPolicies,
+		"datacenter": cluster.Datacenter,
+		"token_type": string(tokenType),
+		"ttl":        ttl,
+		"max_ttl":    maxTTL,
+	}
+
+	r := km.VaultClient.NewRequest("PUT", fmt.Sprintf("/v1/%s", consulRolePath(name)))
+	if err := r.SetJSONBody(body); err != nil {
+		err = errors.Wrapf(err, "failed to set json body on request")
+		return err
+	}
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+
+	resp, err := km.VaultClient.RawRequestWithContext(ctx, r)
+	if err != nil {
+		err = errors.Wrapf(err, "consul role write failed for %s", name)
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	return err
+}
+
+// WriteConsulAuth provisions a Vault Consul secrets-engine role for role on
+// cluster so it can mint Consul ACL tokens carrying consulPolicies, the
+// same way WriteK8sAuth provisions k8s auth for a Role. cluster's Address
+// and CABundle are pushed to consul/config/access and its Datacenter is
+// recorded on the role so Vault knows which Consul catalog to mint against.
+func (km *KeyMaster) WriteConsulAuth(role *Role, env Environment, cluster ConsulCluster, consulPolicies []string, tokenType ConsulTokenType, ttl string, maxTTL string) (err error) {
+	name, err := km.PolicyName(role.Name, role.Namespace, env)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to create policy name")
+		return err
+	}
+
+	return km.writeConsulRole(name, cluster, consulPolicies, tokenType, ttl, maxTTL)
+}
+
+// ReadConsulAuth fetches role's Consul secrets-engine role from Vault.
+func (km *KeyMaster) ReadConsulAuth(role *Role, env Environment) (data map[string]interface{}, err error) {
+	name, err := km.PolicyName(role.Name, role.Namespace, env)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to create policy name")
+		return data, err
+	}
+
+	return km.readConsulRole(name)
+}
+
+func (km *KeyMaster) readConsulRole(name string) (data map[string]interface{}, err error) {
+	s, err := km.VaultClient.Logical().Read(consulRolePath(name))
+	if err != nil {
+		err = errors.Wrapf(err, "failed to read consul role %s", name)
+		return data, err
+	}
+
+	if s != nil {
+		data = s.Data
+	}
+
+	return data, err
+}
+
+// AddPolicyToConsulRole merges consulPolicy into role's Consul
+// secrets-engine role, leaving its token_type/ttl/max_ttl untouched. The
+// datacenter is rewritten from cluster.
+func (km *KeyMaster) AddPolicyToConsulRole(role *Role, env Environment, cluster ConsulCluster, consulPolicy string) (err error) {
+	name, err := km.PolicyName(role.Name, role.Namespace, env)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to create policy name")
+		return err
+	}
+
+	data, err := km.readConsulRole(name)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to read consul role")
+		return err
+	}
+
+	policies := stringsFromInterfaceSlice(data["policies"])
+	if !containsString(policies, consulPolicy) {
+		policies = append(policies, consulPolicy)
+	}
+
+	return km.writeConsulRole(name, cluster, policies, ConsulTokenType(stringFromData(data, "token_type")), durationStringFromData(data, "ttl"), durationStringFromData(data, "max_ttl"))
+}
+
+// RemoveConsulPolicyFromRole removes consulPolicy from role's Consul
+// secrets-engine role.
+func (km *KeyMaster) RemoveConsulPolicyFromRole(role *Role, env Environment, cluster ConsulCluster, consulPolicy string) (err error) {
+	name, err := km.PolicyName(role.Name, role.Namespace, env)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to create policy name")
+		return err
+	}
+
+	data, err := km.readConsulRole(name)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to read consul role")
+		return err
+	}
+
+	policies := removeString(stringsFromInterfaceSlice(data["policies"]), consulPolicy)
+
+	return km.writeConsulRole(name, cluster, policies, ConsulTokenType(stringFromData(data, "token_type")), durationStringFromData(data, "ttl"), durationStringFromData(data, "max_ttl"))
+}
+
+// WriteConsulAuth writes role's Consul secrets-engine role under this
+// enclave's policy namespace, so Consul role names stay unique per tenant
+// the same way Enclave.PolicyName already scopes Vault policies.
+func (e *Enclave) WriteConsulAuth(role *Role, env Environment, cluster ConsulCluster, consulPolicies []string, tokenType ConsulTokenType, ttl string, maxTTL string) (err error) {
+	name, err := e.PolicyName(role.Name, role.Namespace, env)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to create policy name")
+		return err
+	}
+
+	return e.writeConsulRole(name, cluster, consulPolicies, tokenType, ttl, maxTTL)
+}
+
+// ReadConsulAuth fetches role's Consul secrets-engine role from this
+// enclave's policy namespace.
+func (e *Enclave) ReadConsulAuth(role *Role, env Environment) (data map[string]interface{}, err error) {
+	name, err := e.PolicyName(role.Name, role.Namespace, env)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to create policy name")
+		return data, err
+	}
+
+	return e.KeyMaster.readConsulRole(name)
+}
+
+// AddPolicyToConsulRole merges consulPolicy into role's Consul
+// secrets-engine role under this enclave's policy namespace, leaving its
+// token_type/ttl/max_ttl untouched. The datacenter is rewritten from cluster.
+func (e *Enclave) AddPolicyToConsulRole(role *Role, env Environment, cluster ConsulCluster, consulPolicy string) (err error) {
+	name, err := e.PolicyName(role.Name, role.Namespace, env)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to create policy name")
+		return err
+	}
+
+	data, err := e.KeyMaster.readConsulRole(name)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to read consul role")
+		return err
+	}
+
+	policies := stringsFromInterfaceSlice(data["policies"])
+	if !containsString(policies, consulPolicy) {
+		policies = append(policies, consulPolicy)
+	}
+
+	return e.KeyMaster.writeConsulRole(name, cluster, policies, ConsulTokenType(stringFromData(data, "token_type")), durationStringFromData(data, "ttl"), durationStringFromData(data, "max_ttl"))
+}
+
+// RemoveConsulPolicyFromRole removes consulPolicy from role's Consul
+// secrets-engine role under this enclave's policy namespace.
+func (e *Enclave) RemoveConsulPolicyFromRole(role *Role, env Environment, cluster ConsulCluster, consulPolicy string) (err error) {
+	name, err := e.PolicyName(role.Name, role.Namespace, env)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to create policy name")
+		return err
+	}
+
+	data, err := e.KeyMaster.readConsulRole(name)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to read consul role")
+		return err
+	}
+
+	policies := removeString(stringsFromInterfaceSlice(data["policies"]), consulPolicy)
+
+	return e.KeyMaster.writeConsulRole(name, cluster, policies, ConsulTokenType(stringFromData(data, "token_type")), durationStringFromData(data, "ttl"), durationStringFromData(data, "max_ttl"))
+}
@@ -0,0 +1,166 @@
+package keymaster
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsulAuthCrud(t *testing.T) {
+	km := NewKeyMaster(kmClient)
+
+	role := &Role{
+		Name: "app1",
+		Team: "core-services",
+		Realms: []*Realm{
+			&Realm{
+				Type:        RealmConsul,
+				Identifiers: []string{"consul-bravo"},
+			},
+		},
+	}
+
+	cluster := ConsulCluster{
+		Address:    "consul.service.consul:8500",
+		Datacenter: "bravo",
+		CABundle:   "/etc/vault/consul-ca.pem",
+	}
+
+	err := km.WriteConsulAuth(role, "development", cluster, []string{"core-services-app1-development"}, ConsulClientToken, "1h", "24h")
+	if err != nil {
+		log.Printf("Error writing consul auth: %s", err)
+		t.Fail()
+	}
+
+	authData, err := km.ReadConsulAuth(role, "development")
+	if err != nil {
+		fmt.Printf("Failed reading consul auth: %s", err)
+		t.Fail()
+	}
+
+	assert.True(t, reflect.DeepEqual(authData["policies"], []interface{}{"core-services-app1-development"}))
+	assert.Equal(t, "client", authData["token_type"])
+
+	err = km.AddPolicyToConsulRole(role, "development", cluster, "core-services-app2-development")
+	if err != nil {
+		fmt.Printf("Failed adding consul policy: %s", err)
+		t.Fail()
+	}
+
+	authData, err = km.ReadConsulAuth(role, "development")
+	if err != nil {
+		fmt.Printf("Failed reading consul auth: %s", err)
+		t.Fail()
+	}
+
+	assert.True(t, reflect.DeepEqual(authData["policies"], []interface{}{
+		"core-services-app1-development",
+		"core-services-app2-development",
+	}))
+
+	err = km.RemoveConsulPolicyFromRole(role, "development", cluster, "core-services-app2-development")
+	if err != nil {
+		fmt.Printf("Failed removing consul policy: %s", err)
+		t.Fail()
+	}
+
+	authData, err = km.ReadConsulAuth(role, "development")
+	if err != nil {
+		fmt.Printf("Failed reading consul auth: %s", err)
+		t.Fail()
+	}
+
+	assert.True(t, reflect.DeepEqual(authData["policies"], []interface{}{"core-services-app1-development"}))
+	assert.Equal(t, "bravo", authData["datacenter"])
+}
+
+func TestEnclaveConsulAuthCrud(t *testing.T) {
+	km := NewKeyMaster(kmClient)
+
+	enclave, err := km.NewEnclave("acme")
+	assert.NoError(t, err)
+
+	role := &Role{
+		Name: "app1",
+		Team: "core-services",
+		Realms: []*Realm{
+			&Realm{
+				Type:        RealmConsul,
+				Identifiers: []string{"consul-bravo"},
+			},
+		},
+	}
+
+	cluster := ConsulCluster{
+		Address:    "consul.service.consul:8500",
+		Datacenter: "bravo",
+		CABundle:   "/etc/vault/consul-ca.pem",
+	}
+
+	// TestConsulAuthCrud writes this same role under the shared name, so the
+	// leak check below compares against what is there before the enclave
+	// writes rather than against an empty role.
+	sharedBefore, err := km.ReadConsulAuth(role, "development")
+	if err != nil {
+		fmt.Printf("Failed reading shared consul auth: %s", err)
+		t.Fail()
+	}
+
+	err = enclave.WriteConsulAuth(role, "development", cluster, []string{"acme/development-core-services-app1"}, ConsulClientToken, "1h", "24h")
+	if err != nil {
+		log.Printf("Error writing enclave consul auth: %s", err)
+		t.Fail()
+	}
+
+	authData, err := enclave.ReadConsulAuth(role, "development")
+	if err != nil {
+		fmt.Printf("Failed reading enclave consul auth: %s", err)
+		t.Fail()
+	}
+
+	assert.True(t, reflect.DeepEqual(authData["policies"], []interface{}{"acme/development-core-services-app1"}))
+
+	err = enclave.AddPolicyToConsulRole(role, "development", cluster, "acme/development-core-services-app2")
+	if err != nil {
+		fmt.Printf("Failed adding enclave consul policy: %s", err)
+		t.Fail()
+	}
+
+	authData, err = enclave.ReadConsulAuth(role, "development")
+	if err != nil {
+		fmt.Printf("Failed reading enclave consul auth: %s", err)
+		t.Fail()
+	}
+
+	assert.True(t, reflect.DeepEqual(authData["policies"], []interface{}{
+		"acme/development-core-services-app1",
+		"acme/development-core-services-app2",
+	}))
+
+	// The enclave's consul role must live under its own policy-scoped name,
+	// not the shared name WriteConsulAuth uses directly.
+	sharedAuthData, err := km.ReadConsulAuth(role, "development")
+	if err != nil {
+		fmt.Printf("Failed reading shared consul auth: %s", err)
+		t.Fail()
+	}
+
+	assert.Equal(t, sharedBefore["policies"], sharedAuthData["policies"], "enclave consul role leaked onto the shared name")
+
+	err = enclave.RemoveConsulPolicyFromRole(role, "development", cluster, "acme/development-core-services-app2")
+	if err != nil {
+		fmt.Printf("Failed removing enclave consul policy: %s", err)
+		t.Fail()
+	}
+
+	authData, err = enclave.ReadConsulAuth(role, "development")
+	if err != nil {
+		fmt.Printf("Failed reading enclave consul auth: %s", err)
+		t.Fail()
+	}
+
+	assert.True(t, reflect.DeepEqual(authData["policies"], []interface{}{"acme/development-core-services-app1"}))
+}
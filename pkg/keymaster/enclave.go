@@ -0,0 +1,265 @@
+package keymaster
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// Enclave scopes a KeyMaster to a single tenant's policy namespace, auth
+// mount prefix, and secret path prefix, the same separation KES gets from
+// its Enclave concept. Names built through an Enclave take the form
+// <enclave>/<env>-<namespace>-<role>, and the Vault paths they produce live
+// under an enclave-specific prefix (sys/policy/<enclave>/..., secret/<enclave>/...)
+// so multiple teams can share one Vault without cross-visibility.
+type Enclave struct {
+	*KeyMaster
+
+	// Name is the tenant identifier and becomes the leading path segment
+	// for every policy name and Vault path produced through this Enclave.
+	Name string
+
+	// AuthMountPrefix is prepended to auth mount paths, e.g. "acme" turns
+	// "auth/k8s-bravo-dev" into "auth/acme/k8s-bravo-dev".
+	AuthMountPrefix string
+
+	// SecretPrefix is prepended to secret engine paths, e.g. "secret/acme/...".
+	SecretPrefix string
+}
+
+// NewEnclave creates an Enclave scoped to name, deriving its auth mount and
+// secret prefixes from the enclave name unless the caller overrides them.
+func (km *KeyMaster) NewEnclave(name string) (enclave *Enclave, err error) {
+	if name == "" {
+		err = errors.New("empty enclave names are not supported")
+		return enclave, err
+	}
+
+	enclave = &Enclave{
+		KeyMaster:       km,
+		Name:            name,
+		AuthMountPrefix: name,
+		SecretPrefix:    name,
+	}
+
+	return enclave, err
+}
+
+// WithEnclave is the entry point callers use when a single KeyMaster
+// instance provisions policy and auth for more than one tenant. It is an
+// alias for NewEnclave kept separate so call sites read as "operate within
+// this enclave" rather than "create a new one".
+func (km *KeyMaster) WithEnclave(name string) (enclave *Enclave, err error) {
+	return km.NewEnclave(name)
+}
+
+// PolicyName constructs an enclave-scoped policy name: <enclave>/<env>-<namespace>-<role>.
+func (e *Enclave) PolicyName(role string, namespace string, env Environment) (name string, err error) {
+	base, err := e.KeyMaster.PolicyName(role, namespace, env)
+	if err != nil {
+		return name, err
+	}
+
+	name = fmt.Sprintf("%s/%s", e.Name, base)
+
+	return name, err
+}
+
+// PolicyPath constructs the Vault path to the policy, mounted under the
+// enclave's own sys/policy namespace.
+func (e *Enclave) PolicyPath(role string, namespace string, env Environment) (path string, err error) {
+	name, err := e.PolicyName(role, namespace, env)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to create policy name")
+		return path, err
+	}
+
+	path = fmt.Sprintf("sys/policy/%s", name)
+
+	return path, err
+}
+
+// SecretPath constructs the Vault path to a secret, mounted under the
+// enclave's secret prefix so tenants cannot see each other's secrets.
+func (e *Enclave) SecretPath(name string, namespace string, env Environment) (path string, err error) {
+	base, err := e.KeyMaster.SecretPath(name, namespace, env)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to create secret path")
+		return path, err
+	}
+
+	path = fmt.Sprintf("secret/%s/%s", e.SecretPrefix, strings.TrimPrefix(base, "secret/"))
+
+	return path, err
+}
+
+// MakePolicyPayload builds the access policy payload for role under this
+// enclave: every secret path and the self-read on the policy's own path are
+// built through Enclave.SecretPath/Enclave.PolicyPath rather than the
+// embedded KeyMaster's, so the capabilities granted stay inside the
+// enclave's own path prefix instead of leaking onto the shared global
+// paths. Without this override, a tenant's policy would carry the right
+// enclave-scoped name but grant read on the global secret/sys paths,
+// defeating the isolation an Enclave exists to provide.
+func (e *Enclave) MakePolicyPayload(role *Role, env Environment) (policy map[string]interface{}, err error) {
+	policy = make(map[string]interface{})
+	pathElem := make(map[string]interface{})
+
+	for _, secret := range role.Secrets {
+		secretPath, err := e.SecretPath(secret.Name, secret.Namespace, env)
+		if err != nil {
+			err = errors.Wrapf(err, "failed to create secret path for %s role %s", secret.Name, role.Name)
+			return policy, err
+		}
+
+		pathElem[secretPath] = map[string]interface{}{"capabilities": []interface{}{"read"}}
+	}
+
+	selfPath, err := e.PolicyPath(role.Name, role.Namespace, env)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to create policy path")
+		return policy, err
+	}
+
+	pathElem[selfPath] = map[string]interface{}{"capabilities": []interface{}{"read"}}
+	policy["path"] = pathElem
+
+	return policy, err
+}
+
+// NewPolicy creates a new Policy object for role and env under this
+// enclave's policy namespace, with every path inside the payload scoped to
+// the enclave via MakePolicyPayload.
+func (e *Enclave) NewPolicy(role *Role, env Environment) (policy VaultPolicy, err error) {
+	payload, err := e.MakePolicyPayload(role, env)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to create payload")
+		return policy, err
+	}
+
+	name, err := e.PolicyName(role.Name, role.Namespace, env)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to create policy name")
+		return policy, err
+	}
+
+	path, err := e.PolicyPath(role.Name, role.Namespace, env)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to create policy path")
+		return policy, err
+	}
+
+	policy = VaultPolicy{
+		Name:    name,
+		Path:    path,
+		Payload: payload,
+	}
+
+	return policy, err
+}
+
+// authMount rewrites a mount path so the enclave's auth backends live in
+// their own namespace, e.g. "auth/k8s-bravo-dev" becomes
+// "auth/acme/k8s-bravo-dev". Each realm-specific writer (WriteK8sAuth,
+// WriteConsulAuth, WriteAwsRole, ...) gets its own enclave-aware override
+// alongside the commit that introduces that realm; the writers that mount
+// under auth/ route their mount path through this helper before delegating
+// to the embedded KeyMaster, so one set of Vault auth mounts can be shared
+// across tenants without cross-visibility.
+func (e *Enclave) authMount(mount string) string {
+	return fmt.Sprintf("auth/%s/%s", e.AuthMountPrefix, strings.TrimPrefix(mount, "auth/"))
+}
+
+func namespacedAuthMounts(mounts map[Environment]string, namespace func(string) string) map[Environment]string {
+	namespaced := make(map[Environment]string, len(mounts))
+	for env, mount := range mounts {
+		namespaced[env] = namespace(mount)
+	}
+
+	return namespaced
+}
+
+// EnclaveConfig is the on-disk representation of an Enclave, loaded from
+// YAML by LoadEnclavesFromFile.
+type EnclaveConfig struct {
+	Name            string `yaml:"name"`
+	AuthMountPrefix string `yaml:"auth_mount_prefix"`
+	SecretPrefix    string `yaml:"secret_prefix"`
+}
+
+// LoadEnclavesFromFile reads a YAML file containing a list of EnclaveConfig
+// entries and instantiates an Enclave for each one.
+func (km *KeyMaster) LoadEnclavesFromFile(path string) (enclaves []*Enclave, err error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to read enclave config %s", path)
+		return enclaves, err
+	}
+
+	var configs []EnclaveConfig
+	if err = yaml.Unmarshal(raw, &configs); err != nil {
+		err = errors.Wrapf(err, "failed to parse enclave config %s", path)
+		return enclaves, err
+	}
+
+	for _, cfg := range configs {
+		enclave, err := km.NewEnclave(cfg.Name)
+		if err != nil {
+			err = errors.Wrapf(err, "failed to create enclave %s", cfg.Name)
+			return enclaves, err
+		}
+
+		if cfg.AuthMountPrefix != "" {
+			enclave.AuthMountPrefix = cfg.AuthMountPrefix
+		}
+
+		if cfg.SecretPrefix != "" {
+			enclave.SecretPrefix = cfg.SecretPrefix
+		}
+
+		enclaves = append(enclaves, enclave)
+	}
+
+	return enclaves, err
+}
+
+// MigratePolicyFromLegacy reads a pre-existing single-tenant policy from
+// legacyPath and rewrites it into this enclave's namespace, writing the
+// result to the enclave-scoped path for role/namespace/env. The legacy
+// policy is left untouched; callers should verify the migrated copy before
+// deleting it with DeletePolicyFromVault.
+func (e *Enclave) MigratePolicyFromLegacy(legacyPath string, role string, namespace string, env Environment) (policy VaultPolicy, err error) {
+	legacy, err := e.KeyMaster.ReadPolicyFromVault(legacyPath)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to read legacy policy %s", legacyPath)
+		return policy, err
+	}
+
+	name, err := e.PolicyName(role, namespace, env)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to create enclave policy name")
+		return policy, err
+	}
+
+	path, err := e.PolicyPath(role, namespace, env)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to create enclave policy path")
+		return policy, err
+	}
+
+	policy = VaultPolicy{
+		Name:    name,
+		Path:    path,
+		Payload: legacy.Payload,
+	}
+
+	if err = e.WritePolicyToVault(policy); err != nil {
+		err = errors.Wrapf(err, "failed to write migrated policy %s", name)
+		return policy, err
+	}
+
+	return policy, err
+}
@@ -0,0 +1,72 @@
+package keymaster
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnclavePolicyName(t *testing.T) {
+	km := NewKeyMaster(kmClient)
+
+	enclave, err := km.NewEnclave("acme")
+	assert.NoError(t, err)
+
+	name, err := enclave.PolicyName("app1", "core-services", Dev)
+	assert.NoError(t, err)
+	assert.Equal(t, "acme/development-core-services-app1", name)
+}
+
+func TestEnclavePolicyPath(t *testing.T) {
+	km := NewKeyMaster(kmClient)
+
+	enclave, err := km.NewEnclave("acme")
+	assert.NoError(t, err)
+
+	path, err := enclave.PolicyPath("app1", "core-services", Dev)
+	assert.NoError(t, err)
+	assert.Equal(t, "sys/policy/acme/development-core-services-app1", path)
+}
+
+func TestEnclaveSecretPath(t *testing.T) {
+	km := NewKeyMaster(kmClient)
+
+	enclave, err := km.NewEnclave("acme")
+	assert.NoError(t, err)
+
+	path, err := enclave.SecretPath("foo", "core-services", Dev)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret/acme/development/core-services/foo", path)
+}
+
+func TestEnclaveMakePolicyPayloadStaysInsideEnclave(t *testing.T) {
+	km := NewKeyMaster(kmClient)
+
+	enclave, err := km.NewEnclave("acme")
+	assert.NoError(t, err)
+
+	role := &Role{
+		Name:      "app1",
+		Namespace: "core-services",
+		Secrets: []*Secret{
+			{Name: "foo", Namespace: "core-services"},
+		},
+	}
+
+	policy, err := enclave.NewPolicy(role, Dev)
+	assert.NoError(t, err)
+
+	paths, ok := policy.Payload["path"].(map[string]interface{})
+	assert.True(t, ok)
+
+	for path := range paths {
+		assert.Contains(t, path, "acme/", "policy path %s leaked outside the enclave", path)
+	}
+}
+
+func TestNewEnclaveRejectsEmptyName(t *testing.T) {
+	km := NewKeyMaster(kmClient)
+
+	_, err := km.NewEnclave("")
+	assert.Error(t, err)
+}
@@ -0,0 +1,254 @@
+package keymaster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// AuthMount resolves the Vault k8s auth mount path for env, e.g. dev maps
+// to "auth/k8s-bravo-dev". This is the choke point WriteK8sAuth,
+// ReadK8sAuth, AddPolicyToK8sRole, and RemovePolicyFromK8sRole all route
+// through so that dev/stage/prod k8s auth stays on separate mounts instead
+// of sharing one cluster-wide mount, per the auth isolation described at
+// the bottom of policy.go.
+func (c Cluster) AuthMount(env Environment) (mount string, err error) {
+	mount, ok := c.AuthMounts[env]
+	if !ok {
+		err = errors.Errorf("cluster %s has no auth mount configured for environment %v", c.Name, env)
+		return mount, err
+	}
+
+	return mount, err
+}
+
+func k8sRolePath(mount string, roleName string) string {
+	return fmt.Sprintf("%s/role/%s", mount, roleName)
+}
+
+// WriteK8sAuth provisions a Vault k8s auth role on cluster's mount for env
+// so that service accounts matching realm can log in and receive policies.
+func (km *KeyMaster) WriteK8sAuth(cluster Cluster, role *Role, realm *Realm, policies []string, env Environment) (err error) {
+	return km.writeK8sAuth(cluster, role, realm, policies, env)
+}
+
+func (km *KeyMaster) writeK8sAuth(cluster Cluster, role *Role, realm *Realm, policies []string, env Environment) (err error) {
+	mount, err := cluster.AuthMount(env)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to resolve auth mount for %s", cluster.Name)
+		return err
+	}
+
+	body := map[string]interface{}{
+		"bound_service_account_names":      realm.Principals,
+		"bound_service_account_namespaces": realm.Principals,
+		"bound_cidrs":                      cluster.BoundCidrs,
+		"token_bound_cidrs":                cluster.BoundCidrs,
+		"policies":                         policies,
+		"token_policies":                   policies,
+		"token_type":                       "default",
+	}
+
+	r := km.VaultClient.NewRequest("PUT", fmt.Sprintf("/v1/%s", k8sRolePath(mount, role.Name)))
+	if err := r.SetJSONBody(body); err != nil {
+		err = errors.Wrapf(err, "failed to set json body on request")
+		return err
+	}
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+
+	resp, err := km.VaultClient.RawRequestWithContext(ctx, r)
+	if err != nil {
+		err = errors.Wrapf(err, "k8s auth role write failed for %s", role.Name)
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	return err
+}
+
+// ReadK8sAuth fetches role's k8s auth role from cluster's mount for env.
+func (km *KeyMaster) ReadK8sAuth(cluster Cluster, role *Role, env Environment) (data map[string]interface{}, err error) {
+	return km.readK8sAuth(cluster, role, env)
+}
+
+func (km *KeyMaster) readK8sAuth(cluster Cluster, role *Role, env Environment) (data map[string]interface{}, err error) {
+	mount, err := cluster.AuthMount(env)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to resolve auth mount for %s", cluster.Name)
+		return data, err
+	}
+
+	s, err := km.VaultClient.Logical().Read(k8sRolePath(mount, role.Name))
+	if err != nil {
+		err = errors.Wrapf(err, "failed to read k8s auth role %s", role.Name)
+		return data, err
+	}
+
+	if s != nil {
+		data = s.Data
+	}
+
+	return data, err
+}
+
+// AddPolicyToK8sRole merges policy into role's k8s auth role on cluster's
+// mount for env, leaving every other field on the role untouched.
+func (km *KeyMaster) AddPolicyToK8sRole(cluster Cluster, role *Role, realm *Realm, policy VaultPolicy, env Environment) (err error) {
+	data, err := km.readK8sAuth(cluster, role, env)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to read k8s auth role")
+		return err
+	}
+
+	policies := stringsFromInterfaceSlice(data["policies"])
+	if !containsString(policies, policy.Name) {
+		policies = append(policies, policy.Name)
+	}
+
+	return km.writeK8sAuth(cluster, role, realm, policies, env)
+}
+
+// RemovePolicyFromK8sRole removes policy from role's k8s auth role on
+// cluster's mount for env.
+func (km *KeyMaster) RemovePolicyFromK8sRole(cluster Cluster, role *Role, realm *Realm, policy VaultPolicy, env Environment) (err error) {
+	data, err := km.readK8sAuth(cluster, role, env)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to read k8s auth role")
+		return err
+	}
+
+	policies := removeString(stringsFromInterfaceSlice(data["policies"]), policy.Name)
+
+	return km.writeK8sAuth(cluster, role, realm, policies, env)
+}
+
+// authMountTuning is the set of Vault sys/auth tune parameters
+// EnsureAuthMounts sets for each environment's k8s auth mount.
+type authMountTuning struct {
+	DefaultLeaseTTL string
+	MaxLeaseTTL     string
+}
+
+// authMountTuningByEnv mirrors the dev/stage/prod isolation called out at
+// the bottom of policy.go: prod gets long-lived tokens, dev churns fast.
+var authMountTuningByEnv = map[Environment]authMountTuning{
+	Prod:  {DefaultLeaseTTL: "1h", MaxLeaseTTL: "4h"},
+	Stage: {DefaultLeaseTTL: "1h", MaxLeaseTTL: "2h"},
+	Dev:   {DefaultLeaseTTL: "30m", MaxLeaseTTL: "1h"},
+}
+
+// EnsureAuthMounts idempotently creates and tunes cluster's per-environment
+// k8s auth mounts, so operators don't have to hand-provision auth/k8s-*-dev,
+// auth/k8s-*-stage, and auth/k8s-*-prod before calling WriteK8sAuth.
+func (km *KeyMaster) EnsureAuthMounts(cluster Cluster) (err error) {
+	for env, mount := range cluster.AuthMounts {
+		if err = km.ensureAuthMount(mount, authMountTuningByEnv[env]); err != nil {
+			err = errors.Wrapf(err, "failed to ensure auth mount %s", mount)
+			return err
+		}
+	}
+
+	return err
+}
+
+func (km *KeyMaster) ensureAuthMount(mount string, tuning authMountTuning) (err error) {
+	mounts, err := km.VaultClient.Sys().ListAuth()
+	if err != nil {
+		err = errors.Wrapf(err, "failed to list auth mounts")
+		return err
+	}
+
+	// mount carries the "auth/" prefix used for logical paths; sys/auth and
+	// ListAuth key auth backends by the path without it.
+	authPath := strings.TrimPrefix(mount, "auth/")
+
+	if _, exists := mounts[authPath+"/"]; !exists {
+		sysPath := fmt.Sprintf("sys/auth/%s", authPath)
+		body := map[string]interface{}{
+			"type": "kubernetes",
+			"config": map[string]interface{}{
+				"default_lease_ttl": tuning.DefaultLeaseTTL,
+				"max_lease_ttl":     tuning.MaxLeaseTTL,
+			},
+		}
+
+		if _, err = km.VaultClient.Logical().Write(sysPath, body); err != nil {
+			err = errors.Wrapf(err, "failed to create mount %s", mount)
+			return err
+		}
+
+		return err
+	}
+
+	tunePath := fmt.Sprintf("sys/mounts/%s/tune", mount)
+	tune := map[string]interface{}{
+		"default_lease_ttl": tuning.DefaultLeaseTTL,
+		"max_lease_ttl":     tuning.MaxLeaseTTL,
+	}
+
+	if _, err = km.VaultClient.Logical().Write(tunePath, tune); err != nil {
+		err = errors.Wrapf(err, "failed to tune mount %s", mount)
+		return err
+	}
+
+	return err
+}
+
+// WriteK8sAuth writes role's k8s auth role under this enclave's own auth
+// mount namespace (e.g. "auth/acme/k8s-bravo-dev") instead of the shared
+// cluster-wide one, so tenants sharing a Vault cluster can't see each
+// other's k8s auth roles.
+func (e *Enclave) WriteK8sAuth(cluster Cluster, role *Role, realm *Realm, policies []string, env Environment) (err error) {
+	cluster.AuthMounts = namespacedAuthMounts(cluster.AuthMounts, e.authMount)
+
+	return e.KeyMaster.writeK8sAuth(cluster, role, realm, policies, env)
+}
+
+// ReadK8sAuth fetches role's k8s auth role from this enclave's own auth
+// mount namespace, mirroring WriteK8sAuth's isolation.
+func (e *Enclave) ReadK8sAuth(cluster Cluster, role *Role, env Environment) (data map[string]interface{}, err error) {
+	cluster.AuthMounts = namespacedAuthMounts(cluster.AuthMounts, e.authMount)
+
+	return e.KeyMaster.readK8sAuth(cluster, role, env)
+}
+
+// AddPolicyToK8sRole merges policy into role's k8s auth role on this
+// enclave's own auth mount namespace, leaving every other field on the role
+// untouched.
+func (e *Enclave) AddPolicyToK8sRole(cluster Cluster, role *Role, realm *Realm, policy VaultPolicy, env Environment) (err error) {
+	cluster.AuthMounts = namespacedAuthMounts(cluster.AuthMounts, e.authMount)
+
+	data, err := e.KeyMaster.readK8sAuth(cluster, role, env)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to read k8s auth role")
+		return err
+	}
+
+	policies := stringsFromInterfaceSlice(data["policies"])
+	if !containsString(policies, policy.Name) {
+		policies = append(policies, policy.Name)
+	}
+
+	return e.KeyMaster.writeK8sAuth(cluster, role, realm, policies, env)
+}
+
+// RemovePolicyFromK8sRole removes policy from role's k8s auth role on this
+// enclave's own auth mount namespace.
+func (e *Enclave) RemovePolicyFromK8sRole(cluster Cluster, role *Role, realm *Realm, policy VaultPolicy, env Environment) (err error) {
+	cluster.AuthMounts = namespacedAuthMounts(cluster.AuthMounts, e.authMount)
+
+	data, err := e.KeyMaster.readK8sAuth(cluster, role, env)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to read k8s auth role")
+		return err
+	}
+
+	policies := removeString(stringsFromInterfaceSlice(data["policies"]), policy.Name)
+
+	return e.KeyMaster.writeK8sAuth(cluster, role, realm, policies, env)
+}
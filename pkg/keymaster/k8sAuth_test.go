@@ -206,13 +206,13 @@ func TestK8sAuthCrud(t *testing.T) {
 				log.Printf("Error creating policy: %s", err)
 				t.Fail()
 			}
-			err = km.WriteK8sAuth(tc.cluster, tc.role, tc.role.Realms[0], []string{policy.Name})
+			err = km.WriteK8sAuth(tc.cluster, tc.role, tc.role.Realms[0], []string{policy.Name}, "development")
 			if err != nil {
 				fmt.Printf("Failed writing auth: %s", err)
 				t.Fail()
 			}
 
-			authData, err := km.ReadK8sAuth(tc.cluster, tc.role)
+			authData, err := km.ReadK8sAuth(tc.cluster, tc.role, "development")
 			if err != nil {
 				fmt.Printf("Failed reading auth: %s", err)
 				t.Fail()
@@ -220,13 +220,13 @@ func TestK8sAuthCrud(t *testing.T) {
 
 			assert.True(t, reflect.DeepEqual(authData, tc.first))
 
-			err = km.AddPolicyToK8sRole(tc.cluster, tc.role, tc.role.Realms[0], tc.add)
+			err = km.AddPolicyToK8sRole(tc.cluster, tc.role, tc.role.Realms[0], tc.add, "development")
 			if err != nil {
 				fmt.Printf("Failed adding policy")
 				t.Fail()
 			}
 
-			authData, err = km.ReadK8sAuth(tc.cluster, tc.role)
+			authData, err = km.ReadK8sAuth(tc.cluster, tc.role, "development")
 			if err != nil {
 				fmt.Printf("Failed reading auth: %s", err)
 				t.Fail()
@@ -234,13 +234,13 @@ func TestK8sAuthCrud(t *testing.T) {
 
 			assert.True(t, reflect.DeepEqual(authData, tc.second), "role successfully added")
 
-			err = km.RemovePolicyFromK8sRole(tc.cluster, tc.role, tc.role.Realms[0], tc.add)
+			err = km.RemovePolicyFromK8sRole(tc.cluster, tc.role, tc.role.Realms[0], tc.add, "development")
 			if err != nil {
 				fmt.Printf("Failed removing policy")
 				t.Fail()
 			}
 
-			authData, err = km.ReadK8sAuth(tc.cluster, tc.role)
+			authData, err = km.ReadK8sAuth(tc.cluster, tc.role, "development")
 			if err != nil {
 				fmt.Printf("Failed reading auth: %s", err)
 				t.Fail()
@@ -250,3 +250,119 @@ func TestK8sAuthCrud(t *testing.T) {
 		})
 	}
 }
+
+func TestEnclaveK8sAuthCrud(t *testing.T) {
+	km := NewKeyMaster(kmClient)
+
+	enclave, err := km.NewEnclave("acme")
+	assert.NoError(t, err)
+
+	role := &Role{
+		Name: "app1",
+		Secrets: []*Secret{
+			{
+				Name: "foo",
+				Team: "core-services",
+				Generator: AlphaGenerator{
+					Type:   "alpha",
+					Length: 10,
+				},
+			},
+		},
+		Team: "core-services",
+		Realms: []*Realm{
+			&Realm{
+				Type:        "k8s",
+				Identifiers: []string{"bravo"},
+				Principals:  []string{"default"},
+			},
+		},
+	}
+
+	policy, err := enclave.NewPolicy(role, "development")
+	if err != nil {
+		log.Printf("Error creating policy: %s", err)
+		t.Fail()
+	}
+
+	addPolicy, err := enclave.NewPolicy(&Role{
+		Name: "app2",
+		Secrets: []*Secret{
+			{
+				Name: "bar",
+				Team: "core-services",
+				Generator: AlphaGenerator{
+					Type:   "alpha",
+					Length: 10,
+				},
+			},
+		},
+		Team: "core-services",
+	}, "development")
+	if err != nil {
+		log.Printf("Error creating policy: %s", err)
+		t.Fail()
+	}
+
+	// TestK8sAuthCrud writes this same role on the shared mount, so the leak
+	// check below compares against what is there before the enclave writes
+	// rather than against an empty role.
+	sharedBefore, err := km.ReadK8sAuth(Clusters[0], role, "development")
+	if err != nil {
+		fmt.Printf("Failed reading shared auth: %s", err)
+		t.Fail()
+	}
+
+	err = enclave.WriteK8sAuth(Clusters[0], role, role.Realms[0], []string{policy.Name}, "development")
+	if err != nil {
+		fmt.Printf("Failed writing enclave auth: %s", err)
+		t.Fail()
+	}
+
+	authData, err := enclave.ReadK8sAuth(Clusters[0], role, "development")
+	if err != nil {
+		fmt.Printf("Failed reading enclave auth: %s", err)
+		t.Fail()
+	}
+
+	assert.True(t, reflect.DeepEqual(authData["policies"], []interface{}{policy.Name}))
+
+	err = enclave.AddPolicyToK8sRole(Clusters[0], role, role.Realms[0], addPolicy, "development")
+	if err != nil {
+		fmt.Printf("Failed adding enclave policy: %s", err)
+		t.Fail()
+	}
+
+	authData, err = enclave.ReadK8sAuth(Clusters[0], role, "development")
+	if err != nil {
+		fmt.Printf("Failed reading enclave auth: %s", err)
+		t.Fail()
+	}
+
+	assert.True(t, reflect.DeepEqual(authData["policies"], []interface{}{policy.Name, addPolicy.Name}))
+
+	// The enclave's k8s auth role must live under its own auth mount
+	// namespace, not the shared cluster-wide mount WriteK8sAuth uses
+	// directly, or tenants would see each other's role assignments.
+	sharedAuthData, err := km.ReadK8sAuth(Clusters[0], role, "development")
+	if err != nil {
+		fmt.Printf("Failed reading shared auth: %s", err)
+		t.Fail()
+	}
+
+	assert.Equal(t, sharedBefore["policies"], sharedAuthData["policies"], "enclave k8s auth role leaked onto the shared cluster-wide mount")
+
+	err = enclave.RemovePolicyFromK8sRole(Clusters[0], role, role.Realms[0], addPolicy, "development")
+	if err != nil {
+		fmt.Printf("Failed removing enclave policy: %s", err)
+		t.Fail()
+	}
+
+	authData, err = enclave.ReadK8sAuth(Clusters[0], role, "development")
+	if err != nil {
+		fmt.Printf("Failed reading enclave auth: %s", err)
+		t.Fail()
+	}
+
+	assert.True(t, reflect.DeepEqual(authData["policies"], []interface{}{policy.Name}))
+}
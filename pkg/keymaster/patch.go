@@ -0,0 +1,138 @@
+package keymaster
+
+import (
+	"github.com/pkg/errors"
+)
+
+// PatchPolicyInVault applies an RFC 7396 JSON merge-patch to the policy at
+// path and writes the result back to Vault. Unlike the read-modify-write
+// dance callers otherwise have to do by hand, this only requires describing
+// what changed: nil-valued keys in patch delete the matching key from the
+// policy payload, object-valued keys merge recursively, and every other
+// value (including arrays) replaces the target wholesale.
+func (km *KeyMaster) PatchPolicyInVault(path string, patch map[string]interface{}) (policy VaultPolicy, err error) {
+	policy, err = km.ReadPolicyFromVault(path)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to read policy %s for patching", path)
+		return policy, err
+	}
+
+	merged, ok := mergePatch(policy.Payload, patch).(map[string]interface{})
+	if !ok {
+		err = errors.Errorf("merge patch of %s produced a non-object payload", path)
+		return policy, err
+	}
+
+	policy.Payload = merged
+
+	if err = km.WritePolicyToVault(policy); err != nil {
+		err = errors.Wrapf(err, "failed to write patched policy %s", path)
+		return policy, err
+	}
+
+	return policy, err
+}
+
+// mergePatch applies an RFC 7396 JSON merge-patch of patch onto target.
+func mergePatch(target interface{}, patch interface{}) interface{} {
+	patchMap, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetMap, _ := target.(map[string]interface{})
+
+	merged := make(map[string]interface{}, len(targetMap))
+	for k, v := range targetMap {
+		merged[k] = v
+	}
+
+	for k, v := range patchMap {
+		if v == nil {
+			delete(merged, k)
+			continue
+		}
+
+		merged[k] = mergePatch(merged[k], v)
+	}
+
+	return merged
+}
+
+// AddCapabilityToPath merges capability (e.g. "read", "create") into the
+// policy's rule for rulePath, adding the path to the policy if it isn't
+// already governed by it. This lets callers grant access to a single new
+// secret path without reconstructing the whole Role.
+func (km *KeyMaster) AddCapabilityToPath(policyPath string, rulePath string, capability string) (policy VaultPolicy, err error) {
+	current, err := km.ReadPolicyFromVault(policyPath)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to read policy %s", policyPath)
+		return policy, err
+	}
+
+	caps := capabilitiesForPath(current.Payload, rulePath)
+	if !containsString(caps, capability) {
+		caps = append(caps, capability)
+	}
+
+	patch := map[string]interface{}{
+		"path": map[string]interface{}{
+			rulePath: map[string]interface{}{
+				"capabilities": toInterfaceSlice(caps),
+			},
+		},
+	}
+
+	return km.PatchPolicyInVault(policyPath, patch)
+}
+
+// RemoveCapabilityFromPath merges capability out of the policy's rule for
+// rulePath. If it was the last capability for that path, the whole rule is
+// deleted via the merge-patch nil convention rather than left empty.
+func (km *KeyMaster) RemoveCapabilityFromPath(policyPath string, rulePath string, capability string) (policy VaultPolicy, err error) {
+	current, err := km.ReadPolicyFromVault(policyPath)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to read policy %s", policyPath)
+		return policy, err
+	}
+
+	caps := removeString(capabilitiesForPath(current.Payload, rulePath), capability)
+
+	var rule interface{}
+	if len(caps) > 0 {
+		rule = map[string]interface{}{"capabilities": toInterfaceSlice(caps)}
+	}
+
+	patch := map[string]interface{}{
+		"path": map[string]interface{}{
+			rulePath: rule,
+		},
+	}
+
+	return km.PatchPolicyInVault(policyPath, patch)
+}
+
+func capabilitiesForPath(payload map[string]interface{}, rulePath string) (caps []string) {
+	paths, ok := payload["path"].(map[string]interface{})
+	if !ok {
+		return caps
+	}
+
+	rule, ok := paths[rulePath].(map[string]interface{})
+	if !ok {
+		return caps
+	}
+
+	rawCaps, ok := rule["capabilities"].([]interface{})
+	if !ok {
+		return caps
+	}
+
+	for _, c := range rawCaps {
+		if s, ok := c.(string); ok {
+			caps = append(caps, s)
+		}
+	}
+
+	return caps
+}
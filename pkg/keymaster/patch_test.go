@@ -0,0 +1,75 @@
+package keymaster
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergePatch(t *testing.T) {
+	cases := []struct {
+		name   string
+		target map[string]interface{}
+		patch  map[string]interface{}
+		want   map[string]interface{}
+	}{
+		{
+			"adds a new key",
+			map[string]interface{}{"a": "1"},
+			map[string]interface{}{"b": "2"},
+			map[string]interface{}{"a": "1", "b": "2"},
+		},
+		{
+			"nil value deletes the key",
+			map[string]interface{}{"a": "1", "b": "2"},
+			map[string]interface{}{"b": nil},
+			map[string]interface{}{"a": "1"},
+		},
+		{
+			"object values merge recursively",
+			map[string]interface{}{
+				"path": map[string]interface{}{
+					"secret/foo": map[string]interface{}{"capabilities": []interface{}{"read"}},
+				},
+			},
+			map[string]interface{}{
+				"path": map[string]interface{}{
+					"secret/bar": map[string]interface{}{"capabilities": []interface{}{"read"}},
+				},
+			},
+			map[string]interface{}{
+				"path": map[string]interface{}{
+					"secret/foo": map[string]interface{}{"capabilities": []interface{}{"read"}},
+					"secret/bar": map[string]interface{}{"capabilities": []interface{}{"read"}},
+				},
+			},
+		},
+		{
+			"array values replace wholesale",
+			map[string]interface{}{"capabilities": []interface{}{"read", "create"}},
+			map[string]interface{}{"capabilities": []interface{}{"read"}},
+			map[string]interface{}{"capabilities": []interface{}{"read"}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := mergePatch(tc.target, tc.patch)
+			assert.True(t, reflect.DeepEqual(got, tc.want), "got %#v, want %#v", got, tc.want)
+		})
+	}
+}
+
+func TestCapabilitiesForPath(t *testing.T) {
+	payload := map[string]interface{}{
+		"path": map[string]interface{}{
+			"secret/foo": map[string]interface{}{
+				"capabilities": []interface{}{"read", "create"},
+			},
+		},
+	}
+
+	assert.Equal(t, []string{"read", "create"}, capabilitiesForPath(payload, "secret/foo"))
+	assert.Nil(t, capabilitiesForPath(payload, "secret/missing"))
+}
@@ -0,0 +1,193 @@
+package keymaster
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// AccessRequest is a single (path, capability) question posed to
+// SimulatePolicy, mirroring the shape of a real Vault ACL check.
+type AccessRequest struct {
+	Path       string
+	Capability string
+}
+
+// Decision is the result of simulating an AccessRequest against a policy.
+type Decision struct {
+	Allow       bool
+	Subject     string
+	MatchedPath string
+}
+
+// PathChange describes how a single policy path changed between two
+// VaultPolicy payloads.
+type PathChange struct {
+	Path            string
+	OldCapabilities []string
+	NewCapabilities []string
+}
+
+// Diff is the set of path+capability changes between two policy payloads.
+type Diff struct {
+	Added   []PathChange
+	Removed []PathChange
+	Changed []PathChange
+}
+
+// SimulatePolicy evaluates role's generated policy for env against request
+// entirely locally, translating the VaultPolicy payload into an in-memory
+// RBAC-with-path-matching model: each path entry becomes a rule
+// (subject=policy name, object=path, action in capabilities), with the same
+// "+" single-segment and "*" suffix-glob matching Vault's own ACL engine
+// uses. This lets operators check what a policy would allow without
+// touching Vault.
+func (km *KeyMaster) SimulatePolicy(role *Role, env Environment, request AccessRequest) (decision Decision, err error) {
+	policy, err := km.NewPolicy(role, env)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to build simulated policy for %s", role.Name)
+		return decision, err
+	}
+
+	return evaluatePolicy(policy, request), err
+}
+
+func evaluatePolicy(policy VaultPolicy, request AccessRequest) (decision Decision) {
+	decision.Subject = policy.Name
+
+	paths, ok := policy.Payload["path"].(map[string]interface{})
+	if !ok {
+		return decision
+	}
+
+	for pattern, rawRule := range paths {
+		if !matchPath(pattern, request.Path) {
+			continue
+		}
+
+		if containsString(capabilitiesFromRule(rawRule), request.Capability) {
+			decision.Allow = true
+			decision.MatchedPath = pattern
+			return decision
+		}
+	}
+
+	return decision
+}
+
+// matchPath reports whether path satisfies pattern using the same matching
+// Vault applies to ACL policy paths: a trailing "*" matches any suffix, and
+// a "+" segment matches exactly one path segment.
+func matchPath(pattern string, path string) bool {
+	pathSegs := strings.Split(path, "/")
+
+	if strings.HasSuffix(pattern, "*") {
+		prefixSegs := strings.Split(strings.TrimSuffix(pattern, "*"), "/")
+		if len(pathSegs) < len(prefixSegs) {
+			return false
+		}
+
+		last := len(prefixSegs) - 1
+		for i, seg := range prefixSegs[:last] {
+			if seg != "+" && seg != pathSegs[i] {
+				return false
+			}
+		}
+
+		return strings.HasPrefix(pathSegs[last], prefixSegs[last])
+	}
+
+	patternSegs := strings.Split(pattern, "/")
+
+	if len(patternSegs) != len(pathSegs) {
+		return false
+	}
+
+	for i, seg := range patternSegs {
+		if seg == "+" {
+			continue
+		}
+
+		if seg != pathSegs[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// DiffPolicy compares two policy payloads and returns the path+capability
+// tuples that were added, removed, or changed going from old to new.
+func (km *KeyMaster) DiffPolicy(old VaultPolicy, new VaultPolicy) (diff Diff, err error) {
+	oldPaths, _ := old.Payload["path"].(map[string]interface{})
+	newPaths, _ := new.Payload["path"].(map[string]interface{})
+
+	for path, rawRule := range newPaths {
+		newCaps := capabilitiesFromRule(rawRule)
+
+		rawOld, existed := oldPaths[path]
+		if !existed {
+			diff.Added = append(diff.Added, PathChange{Path: path, NewCapabilities: newCaps})
+			continue
+		}
+
+		oldCaps := capabilitiesFromRule(rawOld)
+		if !sameCapabilities(oldCaps, newCaps) {
+			diff.Changed = append(diff.Changed, PathChange{Path: path, OldCapabilities: oldCaps, NewCapabilities: newCaps})
+		}
+	}
+
+	for path, rawRule := range oldPaths {
+		if _, stillPresent := newPaths[path]; stillPresent {
+			continue
+		}
+
+		diff.Removed = append(diff.Removed, PathChange{Path: path, OldCapabilities: capabilitiesFromRule(rawRule)})
+	}
+
+	return diff, err
+}
+
+// DiffCommand previews what WritePolicyToVault would change for role/env by
+// comparing the locally generated policy against what's currently live in
+// Vault. This is the `keymaster diff` entry point operators run before a
+// real write, so a bad policy change shows up as a diff in CI instead of a
+// blind write to Vault.
+func (km *KeyMaster) DiffCommand(role *Role, env Environment) (diff Diff, err error) {
+	proposed, err := km.NewPolicy(role, env)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to build proposed policy for %s", role.Name)
+		return diff, err
+	}
+
+	current, err := km.ReadPolicyFromVault(proposed.Path)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to read current policy %s", proposed.Path)
+		return diff, err
+	}
+
+	return km.DiffPolicy(current, proposed)
+}
+
+func capabilitiesFromRule(rawRule interface{}) []string {
+	rule, ok := rawRule.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	return stringsFromInterfaceSlice(rule["capabilities"])
+}
+
+func sameCapabilities(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for _, c := range a {
+		if !containsString(b, c) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,88 @@
+package keymaster
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchPath(t *testing.T) {
+	cases := []struct {
+		pattern string
+		path    string
+		match   bool
+	}{
+		{"service/sign/cert-issuer", "service/sign/cert-issuer", true},
+		{"service/sign/cert-issuer", "service/sign/other", false},
+		{"service/+/cert-issuer", "service/sign/cert-issuer", true},
+		{"service/+/cert-issuer", "service/sign/extra/cert-issuer", false},
+		{"service/sign/*", "service/sign/cert-issuer/renew", true},
+		{"service/sign/*", "service/other/cert-issuer", false},
+	}
+
+	for _, tc := range cases {
+		assert.Equal(t, tc.match, matchPath(tc.pattern, tc.path), "%s vs %s", tc.pattern, tc.path)
+	}
+}
+
+func TestEvaluatePolicy(t *testing.T) {
+	policy := VaultPolicy{
+		Name: "core-services-app1-development",
+		Payload: map[string]interface{}{
+			"path": map[string]interface{}{
+				"secret/development/core-services/foo": map[string]interface{}{
+					"capabilities": []interface{}{"read"},
+				},
+			},
+		},
+	}
+
+	allowed := evaluatePolicy(policy, AccessRequest{Path: "secret/development/core-services/foo", Capability: "read"})
+	assert.True(t, allowed.Allow)
+	assert.Equal(t, "secret/development/core-services/foo", allowed.MatchedPath)
+
+	denied := evaluatePolicy(policy, AccessRequest{Path: "secret/development/core-services/foo", Capability: "create"})
+	assert.False(t, denied.Allow)
+
+	noMatch := evaluatePolicy(policy, AccessRequest{Path: "secret/development/core-platform/foo", Capability: "read"})
+	assert.False(t, noMatch.Allow)
+}
+
+func TestDiffPolicy(t *testing.T) {
+	km := NewKeyMaster(kmClient)
+
+	old := VaultPolicy{
+		Payload: map[string]interface{}{
+			"path": map[string]interface{}{
+				"secret/development/core-services/foo": map[string]interface{}{
+					"capabilities": []interface{}{"read"},
+				},
+				"secret/development/core-services/bar": map[string]interface{}{
+					"capabilities": []interface{}{"read"},
+				},
+			},
+		},
+	}
+
+	new := VaultPolicy{
+		Payload: map[string]interface{}{
+			"path": map[string]interface{}{
+				"secret/development/core-services/foo": map[string]interface{}{
+					"capabilities": []interface{}{"read", "create"},
+				},
+				"secret/development/core-services/baz": map[string]interface{}{
+					"capabilities": []interface{}{"read"},
+				},
+			},
+		},
+	}
+
+	diff, err := km.DiffPolicy(old, new)
+	assert.NoError(t, err)
+	assert.Len(t, diff.Added, 1)
+	assert.Equal(t, "secret/development/core-services/baz", diff.Added[0].Path)
+	assert.Len(t, diff.Removed, 1)
+	assert.Equal(t, "secret/development/core-services/bar", diff.Removed[0].Path)
+	assert.Len(t, diff.Changed, 1)
+	assert.Equal(t, "secret/development/core-services/foo", diff.Changed[0].Path)
+}
@@ -0,0 +1,83 @@
+package keymaster
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// containsString reports whether s is present in list.
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// removeString returns list with every occurrence of s removed.
+func removeString(list []string, s string) (out []string) {
+	for _, v := range list {
+		if v != s {
+			out = append(out, v)
+		}
+	}
+
+	return out
+}
+
+// toInterfaceSlice converts a []string into the []interface{} form the
+// Vault API expects for JSON-bodied requests.
+func toInterfaceSlice(list []string) []interface{} {
+	out := make([]interface{}, len(list))
+	for i, v := range list {
+		out[i] = v
+	}
+
+	return out
+}
+
+// stringsFromInterfaceSlice converts a decoded JSON array (as returned by
+// the Vault API) back into a []string, skipping any non-string elements.
+func stringsFromInterfaceSlice(raw interface{}) (out []string) {
+	list, ok := raw.([]interface{})
+	if !ok {
+		return out
+	}
+
+	for _, v := range list {
+		if s, ok := v.(string); ok {
+			out = append(out, s)
+		}
+	}
+
+	return out
+}
+
+// stringFromData reads a string-valued field out of a decoded Vault
+// response, returning "" if it is absent or not a string.
+func stringFromData(data map[string]interface{}, key string) string {
+	s, _ := data[key].(string)
+	return s
+}
+
+// durationStringFromData reads a Vault TTL field that was written as a
+// duration string (e.g. "1h") but that Vault normalizes and hands back as a
+// json.Number of seconds, returning "" if the field is absent or neither
+// form.
+func durationStringFromData(data map[string]interface{}, key string) string {
+	switch v := data[key].(type) {
+	case string:
+		return v
+	case json.Number:
+		seconds, err := v.Int64()
+		if err != nil {
+			return ""
+		}
+
+		return (time.Duration(seconds) * time.Second).String()
+	default:
+		return ""
+	}
+}